@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorReset = "\033[0m"
+)
+
+// diffHunk is a single differing subtree between an old and new embedded
+// YAML document, located by a yq-style path.
+type diffHunk struct {
+	Path string
+	Old  *yaml.Node // nil when the path was added
+	New  *yaml.Node // nil when the path was removed
+}
+
+// stdoutIsTTY reports whether stdout is a terminal, so diff output is
+// only colored when a human is actually looking at it.
+func stdoutIsTTY() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return (info.Mode() & os.ModeCharDevice) != 0
+}
+
+// diffEmbeddedYAML parses oldRaw/newRaw and reports the path-oriented
+// differences between them, rooted at basePath (e.g.
+// configmaps[2].data["application.yaml"]).
+func diffEmbeddedYAML(oldRaw, newRaw, basePath string) ([]diffHunk, error) {
+	oldRoot, err := parseMappingRoot(oldRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse old embedded YAML: %w", err)
+	}
+	newRoot, err := parseMappingRoot(newRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse new embedded YAML: %w", err)
+	}
+	return diffNodes(oldRoot, newRoot, basePath), nil
+}
+
+// parseMappingRoot parses raw YAML and returns its root mapping node, or
+// nil if the document is empty.
+func parseMappingRoot(raw string) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(raw), &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return doc.Content[0], nil
+}
+
+// diffNodes recursively compares oldN and newN, returning a hunk per
+// differing leaf or subtree. Either side may be nil to represent an
+// added or removed path.
+func diffNodes(oldN, newN *yaml.Node, path string) []diffHunk {
+	if oldN == nil && newN == nil {
+		return nil
+	}
+	if oldN == nil || newN == nil || oldN.Kind != newN.Kind {
+		return []diffHunk{{Path: path, Old: oldN, New: newN}}
+	}
+
+	switch oldN.Kind {
+	case yaml.MappingNode:
+		var hunks []diffHunk
+		seen := make(map[string]bool, len(oldN.Content)/2)
+		for i := 0; i < len(oldN.Content); i += 2 {
+			key := oldN.Content[i].Value
+			seen[key] = true
+			hunks = append(hunks, diffNodes(oldN.Content[i+1], mappingValue(newN, key), joinPath(path, key))...)
+		}
+		for i := 0; i < len(newN.Content); i += 2 {
+			key := newN.Content[i].Value
+			if seen[key] {
+				continue
+			}
+			hunks = append(hunks, diffNodes(nil, newN.Content[i+1], joinPath(path, key))...)
+		}
+		return hunks
+
+	case yaml.SequenceNode:
+		var hunks []diffHunk
+		n := len(oldN.Content)
+		if len(newN.Content) > n {
+			n = len(newN.Content)
+		}
+		for i := 0; i < n; i++ {
+			var o, nn *yaml.Node
+			if i < len(oldN.Content) {
+				o = oldN.Content[i]
+			}
+			if i < len(newN.Content) {
+				nn = newN.Content[i]
+			}
+			hunks = append(hunks, diffNodes(o, nn, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return hunks
+
+	case yaml.ScalarNode:
+		if oldN.Value != newN.Value || oldN.Tag != newN.Tag {
+			return []diffHunk{{Path: path, Old: oldN, New: newN}}
+		}
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	idx := findMappingKey(mapping, key)
+	if idx == -1 {
+		return nil
+	}
+	return mapping.Content[idx+1]
+}
+
+func joinPath(base, key string) string {
+	if base == "" {
+		return key
+	}
+	return base + "." + key
+}
+
+// printDiffHunks writes a colored, path-oriented diff for filePath to
+// stdout. Colors are only emitted when stdout is a terminal.
+func printDiffHunks(filePath string, hunks []diffHunk) {
+	if len(hunks) == 0 {
+		return
+	}
+	colored := stdoutIsTTY()
+	fmt.Printf("--- %s\n", filePath)
+	for _, hunk := range hunks {
+		fmt.Printf("  %s\n", hunk.Path)
+		if hunk.Old != nil {
+			printSubtree("  - ", hunk.Old, colorRed, colored)
+		}
+		if hunk.New != nil {
+			printSubtree("  + ", hunk.New, colorGreen, colored)
+		}
+	}
+}
+
+func printSubtree(prefix string, node *yaml.Node, color string, colored bool) {
+	out, err := yaml.Marshal(node)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		text := prefix + line
+		if colored {
+			text = color + text + colorReset
+		}
+		fmt.Println(text)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffNodesMapChangedAddedRemoved(t *testing.T) {
+	oldRoot, err := parseMappingRoot("a: 1\nb: 2\nc: 3\n")
+	if err != nil {
+		t.Fatalf("parseMappingRoot: %v", err)
+	}
+	newRoot, err := parseMappingRoot("a: 1\nb: 20\nd: 4\n")
+	if err != nil {
+		t.Fatalf("parseMappingRoot: %v", err)
+	}
+
+	hunks := diffNodes(oldRoot, newRoot, "root")
+
+	byPath := make(map[string]diffHunk, len(hunks))
+	for _, h := range hunks {
+		byPath[h.Path] = h
+	}
+
+	if len(hunks) != 3 {
+		t.Fatalf("expected 3 hunks (changed b, removed c, added d), got %d: %+v", len(hunks), hunks)
+	}
+
+	changed, ok := byPath["root.b"]
+	if !ok || changed.Old == nil || changed.New == nil || changed.Old.Value != "2" || changed.New.Value != "20" {
+		t.Fatalf("expected root.b to be a changed hunk 2->20, got %+v", changed)
+	}
+
+	removed, ok := byPath["root.c"]
+	if !ok || removed.Old == nil || removed.New != nil {
+		t.Fatalf("expected root.c to be a removed hunk, got %+v", removed)
+	}
+
+	added, ok := byPath["root.d"]
+	if !ok || added.Old != nil || added.New == nil {
+		t.Fatalf("expected root.d to be an added hunk, got %+v", added)
+	}
+}
+
+func TestDiffNodesSequenceGrowAndShrink(t *testing.T) {
+	oldRoot, err := parseMappingRoot("list:\n  - a\n  - b\n  - c\n")
+	if err != nil {
+		t.Fatalf("parseMappingRoot: %v", err)
+	}
+	newRoot, err := parseMappingRoot("list:\n  - a\n  - x\n")
+	if err != nil {
+		t.Fatalf("parseMappingRoot: %v", err)
+	}
+
+	hunks := diffNodes(oldRoot, newRoot, "root")
+
+	byPath := make(map[string]diffHunk, len(hunks))
+	for _, h := range hunks {
+		byPath[h.Path] = h
+	}
+
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks (changed [1], removed [2]), got %d: %+v", len(hunks), hunks)
+	}
+
+	changed, ok := byPath["root.list[1]"]
+	if !ok || changed.Old == nil || changed.New == nil || changed.Old.Value != "b" || changed.New.Value != "x" {
+		t.Fatalf("expected root.list[1] to change b->x, got %+v", changed)
+	}
+
+	removed, ok := byPath["root.list[2]"]
+	if !ok || removed.Old == nil || removed.New != nil || removed.Old.Value != "c" {
+		t.Fatalf("expected root.list[2] to be removed (c), got %+v", removed)
+	}
+}
+
+func TestDiffEmbeddedYAMLNoDifference(t *testing.T) {
+	raw := "a: 1\nb:\n  - x\n  - y\n"
+	hunks, err := diffEmbeddedYAML(raw, raw, "configmaps[0].data[\"application.yaml\"]")
+	if err != nil {
+		t.Fatalf("diffEmbeddedYAML: %v", err)
+	}
+	if len(hunks) != 0 {
+		t.Fatalf("expected no hunks comparing identical documents, got %+v", hunks)
+	}
+}
+
+func TestDiffEmbeddedYAMLTypeChange(t *testing.T) {
+	hunks, err := diffEmbeddedYAML("x: hello\n", "x:\n  y: 1\n", "root")
+	if err != nil {
+		t.Fatalf("diffEmbeddedYAML: %v", err)
+	}
+	if len(hunks) != 1 || hunks[0].Path != "root.x" {
+		t.Fatalf("expected a single hunk at root.x for a scalar->mapping change, got %+v", hunks)
+	}
+}
+
+// TestDryRunExitCode drives the built binary's --dry-run flag as a
+// subprocess (the standard way to observe os.Exit behavior from a Go
+// test) to confirm it exits non-zero exactly when something would
+// change, and zero otherwise.
+func TestDryRunExitCode(t *testing.T) {
+	if dir := os.Getenv("AP_TEST_DRYRUN_DIR"); dir != "" {
+		os.Args = []string{"annotation-processor", "--dry-run", dir}
+		main()
+		return
+	}
+
+	run := func(t *testing.T, dataYAML string, wantExit int) {
+		t.Helper()
+		dir := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(dir, "dev", "configmaps"), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		cmYAML := "version: v1\nconfigmaps:\n  - name: app\n    region: eu\n    namespace: team-a\n    data:\n      application.yaml: |\n        " + dataYAML + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "dev", "configmaps", "app.yaml"), []byte(cmYAML), 0644); err != nil {
+			t.Fatalf("write configmap: %v", err)
+		}
+
+		cmd := exec.Command(os.Args[0], "-test.run=TestDryRunExitCode")
+		cmd.Env = append(os.Environ(), "AP_TEST_DRYRUN_DIR="+dir)
+		err := cmd.Run()
+
+		var exitErr *exec.ExitError
+		gotExit := 0
+		if errors.As(err, &exitErr) {
+			gotExit = exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatalf("unexpected error running subprocess: %v", err)
+		}
+		if gotExit != wantExit {
+			t.Fatalf("dry-run exit code = %d, want %d", gotExit, wantExit)
+		}
+	}
+
+	t.Run("change needed exits 1", func(t *testing.T) {
+		run(t, "foo: bar", 1) // default rule will add spring.jackson.*
+	})
+
+	t.Run("already applied exits 0", func(t *testing.T) {
+		run(t, "spring:\n          jackson:\n            default-property-inclusion: non_null", 0)
+	})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// numericScalar matches bare (unquoted) integer/float scalars, e.g.
+// "42", "-3.14", "1e10". Used to type untagged scalars produced by the
+// rule engine (which does not always set node.Tag).
+var numericScalar = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][+-]?\d+)?$`)
+
+// orderedObject is a JSON object that marshals its keys in the order
+// they were added, rather than the sorted order encoding/json would
+// otherwise impose on a map[string]interface{}.
+type orderedObject struct {
+	keys   []string
+	values []any
+}
+
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		keyJSON, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(keyJSON)
+		buf.WriteByte(':')
+		valueJSON, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(valueJSON)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// nodeToJSON converts a yaml.Node tree into JSON-marshalable values,
+// preserving mapping key order via orderedObject and typing scalars
+// faithfully (see scalarToJSON) instead of routing through
+// map[string]interface{}, which would sort keys and mistype
+// numeric-looking strings.
+func nodeToJSON(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return nodeToJSON(node.Content[0])
+
+	case yaml.MappingNode:
+		obj := &orderedObject{}
+		for i := 0; i < len(node.Content); i += 2 {
+			value, err := nodeToJSON(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, node.Content[i].Value)
+			obj.values = append(obj.values, value)
+		}
+		return obj, nil
+
+	case yaml.SequenceNode:
+		arr := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := nodeToJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		return arr, nil
+
+	case yaml.ScalarNode:
+		return scalarToJSON(node)
+
+	case yaml.AliasNode:
+		return nodeToJSON(node.Alias)
+
+	default:
+		return nil, fmt.Errorf("unsupported node kind %v", node.Kind)
+	}
+}
+
+// scalarToJSON types a single scalar node. Quoted scalars (or ones
+// explicitly tagged !!str) always stay strings; otherwise bare
+// true/false/null are converted to their real JSON types. Numerics
+// tagged !!int/!!float by go-yaml's own resolver are parsed as such
+// regardless of their literal form (octal, hex, underscored,
+// explicit-sign, or overflowing int64 all resolve this way); the
+// numericScalar regex is only a fallback for untagged plain-decimal
+// values synthesized by the rule engine.
+func scalarToJSON(node *yaml.Node) (any, error) {
+	if node.Tag == "!!str" || node.Style == yaml.DoubleQuotedStyle || node.Style == yaml.SingleQuotedStyle {
+		return node.Value, nil
+	}
+
+	if node.Tag == "!!null" || node.Value == "null" || node.Value == "~" || node.Value == "" {
+		return nil, nil
+	}
+
+	if node.Tag == "!!bool" || node.Value == "true" || node.Value == "false" {
+		if b, err := strconv.ParseBool(node.Value); err == nil {
+			return b, nil
+		}
+	}
+
+	if node.Tag == "!!int" {
+		if i, err := strconv.ParseInt(node.Value, 0, 64); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(node.Value, 0, 64); err == nil {
+			return u, nil
+		}
+		if f, err := strconv.ParseFloat(node.Value, 64); err == nil {
+			return f, nil
+		}
+		return node.Value, nil
+	}
+
+	if node.Tag == "!!float" {
+		if f, err := strconv.ParseFloat(node.Value, 64); err == nil {
+			return f, nil
+		}
+		return node.Value, nil
+	}
+
+	if numericScalar.MatchString(node.Value) {
+		if strings.ContainsAny(node.Value, ".eE") {
+			if f, err := strconv.ParseFloat(node.Value, 64); err == nil {
+				return f, nil
+			}
+		} else if i, err := strconv.ParseInt(node.Value, 10, 64); err == nil {
+			return i, nil
+		}
+	}
+
+	return node.Value, nil
+}
+
+// embeddedYAMLToJSON converts a raw embedded YAML document into its
+// JSON text form, preserving key order to keep diffs reviewable.
+func embeddedYAMLToJSON(raw string) (string, error) {
+	root, err := parseMappingRoot(raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse embedded YAML: %w", err)
+	}
+	if root == nil {
+		return "{}", nil
+	}
+
+	value, err := nodeToJSON(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert embedded YAML to JSON: %w", err)
+	}
+
+	out, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func scalarFromYAML(t *testing.T, raw string) *yaml.Node {
+	t.Helper()
+	root, err := parseMappingRoot("v: " + raw + "\n")
+	if err != nil {
+		t.Fatalf("parseMappingRoot(%q): %v", raw, err)
+	}
+	idx := findMappingKey(root, "v")
+	return root.Content[idx+1]
+}
+
+func TestScalarToJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want any
+	}{
+		{"bare string", "hello", "hello"},
+		{"quoted numeric-looking string", `"42"`, "42"},
+		{"single-quoted string", `'true'`, "true"},
+		{"bare int", "42", int64(42)},
+		{"negative int", "-7", int64(-7)},
+		{"bare float", "3.14", float64(3.14)},
+		{"exponent float", "1e10", float64(1e10)},
+		{"bare bool true", "true", true},
+		{"bare bool false", "false", false},
+		{"bare null", "null", nil},
+		{"tilde null", "~", nil},
+		{"octal int", "0o17", int64(15)},
+		{"hex int", "0x1A", int64(26)},
+		{"underscored int", "1_000", int64(1000)},
+		{"explicit sign int", "+42", int64(42)},
+		{"overflowing int64", "9223372036854775808", uint64(9223372036854775808)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := scalarFromYAML(t, tt.raw)
+			got, err := scalarToJSON(node)
+			if err != nil {
+				t.Fatalf("scalarToJSON(%q): %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Fatalf("scalarToJSON(%q) = %#v (%T), want %#v (%T)", tt.raw, got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeToJSONPreservesOrderAndNesting(t *testing.T) {
+	raw := "b: 1\na:\n  - x\n  - 2\nc:\n  nested: true\n"
+	root, err := parseMappingRoot(raw)
+	if err != nil {
+		t.Fatalf("parseMappingRoot: %v", err)
+	}
+
+	value, err := nodeToJSON(root)
+	if err != nil {
+		t.Fatalf("nodeToJSON: %v", err)
+	}
+
+	obj, ok := value.(*orderedObject)
+	if !ok {
+		t.Fatalf("nodeToJSON returned %T, want *orderedObject", value)
+	}
+	if got := obj.keys; len(got) != 3 || got[0] != "b" || got[1] != "a" || got[2] != "c" {
+		t.Fatalf("unexpected key order: %v", got)
+	}
+
+	seq, ok := obj.values[1].([]any)
+	if !ok || len(seq) != 2 || seq[0] != "x" || seq[1] != int64(2) {
+		t.Fatalf("unexpected sequence value: %#v", obj.values[1])
+	}
+}
+
+func TestEmbeddedYAMLToJSON(t *testing.T) {
+	raw := "mode: 0o17\nname: app\n"
+	out, err := embeddedYAMLToJSON(raw)
+	if err != nil {
+		t.Fatalf("embeddedYAMLToJSON: %v", err)
+	}
+	want := "{\n  \"mode\": 15,\n  \"name\": \"app\"\n}"
+	if out != want {
+		t.Fatalf("embeddedYAMLToJSON(%q) = %q, want %q", raw, out, want)
+	}
+}
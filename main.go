@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -23,17 +24,29 @@ type ConfigMap struct {
 	Data      map[string]string `yaml:"data"`
 }
 
-// JacksonConfig represents the jackson configuration
-type JacksonConfig struct {
-	DefaultPropertyInclusion string `yaml:"default-property-inclusion"`
-}
+func main() {
+	rulesPath := flag.String("rules", "", "path to a YAML file describing injection rules (defaults to the built-in spring.jackson rule)")
+	dryRun := flag.Bool("dry-run", false, "print a colored diff of what would change instead of writing, and exit non-zero if anything would change")
+	flag.BoolVar(dryRun, "diff", false, "alias for --dry-run")
+	concatSequences := flag.Bool("overlay-concat-sequences", true, "concatenate sequences when merging configmaps.d/ overlay fragments, instead of letting the overlay replace them")
+	emit := flag.String("emit", "yaml", "output format for processed data entries: yaml or json")
+	flag.Parse()
+
+	if *emit != "yaml" && *emit != "json" {
+		fmt.Printf("Error: --emit must be \"yaml\" or \"json\", got %q\n", *emit)
+		os.Exit(1)
+	}
 
-// SpringConfig represents the spring configuration
-type SpringConfig struct {
-	Jackson JacksonConfig `yaml:"jackson"`
-}
+	rules := defaultRules()
+	if *rulesPath != "" {
+		loaded, err := loadRuleSet(*rulesPath)
+		if err != nil {
+			fmt.Printf("Error loading rules: %v\n", err)
+			os.Exit(1)
+		}
+		rules = loaded
+	}
 
-func main() {
 	// Directories to process
 	dirs := []string{
 		"dev/configmaps",
@@ -42,122 +55,253 @@ func main() {
 		"pro/configmaps",
 	}
 
-	// Get base directory from command line argument or use current directory
+	// Get base directory from the first positional argument or use current directory
 	baseDir := "."
-	if len(os.Args) > 1 {
-		baseDir = os.Args[1]
+	if args := flag.Args(); len(args) > 0 {
+		baseDir = args[0]
 	}
 
+	resolver := newRefResolver()
+
+	anyChanged := false
 	for _, dir := range dirs {
 		fullPath := filepath.Join(baseDir, dir)
-		if err := processDirectory(fullPath); err != nil {
+		changed, err := processDirectory(fullPath, rules, *dryRun, *concatSequences, *emit, resolver)
+		if err != nil {
 			fmt.Printf("Warning: Could not process directory %s: %v\n", fullPath, err)
 		}
+		if changed {
+			anyChanged = true
+		}
 	}
 
+	resolver.printSummary()
 	fmt.Println("Processing complete!")
+
+	if *dryRun && anyChanged {
+		os.Exit(1)
+	}
 }
 
-func processDirectory(dirPath string) error {
+func processDirectory(dirPath string, rules []Rule, dryRun, concatSequences bool, emit string, resolver *refResolver) (bool, error) {
 	// Check if directory exists
 	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-		return fmt.Errorf("directory does not exist: %s", dirPath)
+		return false, fmt.Errorf("directory does not exist: %s", dirPath)
 	}
 
 	// Find all YAML files in the directory
 	files, err := filepath.Glob(filepath.Join(dirPath, "*.yaml"))
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	ymlFiles, err := filepath.Glob(filepath.Join(dirPath, "*.yml"))
 	if err != nil {
-		return err
+		return false, err
 	}
 	files = append(files, ymlFiles...)
 
+	overlayDir := overlayDirFor(dirPath)
+
+	anyChanged := false
 	for _, file := range files {
 		fmt.Printf("Processing file: %s\n", file)
-		if err := processFile(file); err != nil {
+		changed, err := processFile(file, rules, dryRun, overlayDir, concatSequences, emit, resolver)
+		if err != nil {
 			fmt.Printf("  Error processing file %s: %v\n", file, err)
 		} else {
 			fmt.Printf("  Successfully processed: %s\n", file)
 		}
+		if changed {
+			anyChanged = true
+		}
 	}
 
-	return nil
+	return anyChanged, nil
 }
 
-func processFile(filePath string) error {
+// processFile merges any configmaps.d/ overlay fragments, resolves
+// $ref pointers, applies rules, and (when emit is "json") converts
+// filePath's embedded YAML payloads on the way out. When dryRun is
+// true, no file is written: a colored diff is printed instead. It
+// reports whether the file would be (or was) modified.
+func processFile(filePath string, rules []Rule, dryRun bool, overlayDir string, concatSequences bool, emit string, resolver *refResolver) (bool, error) {
 	// Read the file
 	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return false, fmt.Errorf("failed to read file: %w", err)
 	}
 
 	// Parse the outer YAML structure
 	var config OuterConfig
 	if err := yaml.Unmarshal(content, &config); err != nil {
-		return fmt.Errorf("failed to parse YAML: %w", err)
+		return false, fmt.Errorf("failed to parse YAML: %w", err)
 	}
 
 	modified := false
+	var hunks []diffHunk
 
 	// Process each configmap
 	for i := range config.ConfigMaps {
 		cm := &config.ConfigMaps[i]
 
-		// Process each data entry
-		for key, value := range cm.Data {
-			if strings.HasSuffix(key, ".yaml") || strings.HasSuffix(key, ".yml") {
-				updatedValue, wasModified, err := addSpringJacksonConfig(value)
-				if err != nil {
-					fmt.Printf("    Warning: Could not process embedded YAML in %s: %v\n", key, err)
-					continue
-				}
-				if wasModified {
-					cm.Data[key] = updatedValue
-					modified = true
-					fmt.Printf("    Modified embedded config in: %s (region: %s)\n", key, cm.Region)
-				}
-			}
+		changed, cmHunks, err := processConfigMapData(i, cm, rules, dryRun, overlayDir, concatSequences, emit, filePath, resolver)
+		if err != nil {
+			fmt.Printf("    Warning: Could not process configmap %s: %v\n", cm.Name, err)
+			continue
+		}
+		if changed {
+			modified = true
+			hunks = append(hunks, cmHunks...)
 		}
 	}
 
 	if !modified {
 		fmt.Printf("  No modifications needed\n")
-		return nil
+		return false, nil
+	}
+
+	if dryRun {
+		printDiffHunks(filePath, hunks)
+		return true, nil
 	}
 
 	// Marshal back to YAML with proper formatting
 	output, err := marshalWithPreservedFormat(&config)
 	if err != nil {
-		return fmt.Errorf("failed to marshal YAML: %w", err)
+		return false, fmt.Errorf("failed to marshal YAML: %w", err)
 	}
 
 	// Write back to file
 	if err := os.WriteFile(filePath, output, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+		return false, fmt.Errorf("failed to write file: %w", err)
 	}
 
-	return nil
+	return true, nil
 }
 
-func addSpringJacksonConfig(embeddedYAML string) (string, bool, error) {
-	// Parse the embedded YAML as a generic map to preserve structure
+// processConfigMapData merges overlay fragments (if any) into cm.Data,
+// resolves $ref pointers, applies rules, and (when emit is "json")
+// converts every embedded YAML entry to JSON, mutating cm.Data in
+// place unless dryRun is set. It returns whether anything changed and,
+// in dry-run mode, the diff hunks describing what would change.
+func processConfigMapData(cmIndex int, cm *ConfigMap, rules []Rule, dryRun bool, overlayDir string, concatSequences bool, emit string, filePath string, resolver *refResolver) (bool, []diffHunk, error) {
+	fragments, err := overlayFragments(overlayDir, cm.Name)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to list overlay fragments: %w", err)
+	}
+
+	if cm.Data == nil && len(fragments) > 0 {
+		cm.Data = make(map[string]string)
+	}
+
+	for _, fragFile := range fragments {
+		key := filepath.Base(fragFile)
+		if _, exists := cm.Data[key]; !exists {
+			cm.Data[key] = ""
+		}
+	}
+
+	modified := false
+	var hunks []diffHunk
+
+	for key, original := range cm.Data {
+		if !strings.HasSuffix(key, ".yaml") && !strings.HasSuffix(key, ".yml") {
+			continue
+		}
+
+		working := original
+		if overlayDir != "" {
+			fragFile := filepath.Join(overlayDir, cm.Name, key)
+			fragContent, err := os.ReadFile(fragFile)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					fmt.Printf("    Warning: Could not read overlay fragment %s: %v\n", fragFile, err)
+				}
+			} else {
+				merged, overlayChanged, err := mergeEmbeddedYAML(working, string(fragContent), concatSequences)
+				if err != nil {
+					return false, nil, fmt.Errorf("overlay fragment %s: %w", fragFile, err)
+				}
+				if overlayChanged {
+					working = merged
+					fmt.Printf("    Merged overlay fragment: %s into %s (region: %s)\n", fragFile, key, cm.Region)
+				}
+			}
+		}
+
+		derefedValue, refsChanged, err := resolveEmbeddedRefs(working, filePath, cm, key, resolver)
+		if err != nil {
+			return false, nil, fmt.Errorf("resolving $ref in %s: %w", key, err)
+		}
+		if refsChanged {
+			working = derefedValue
+		}
+
+		updatedValue, rulesModified, err := applyRulesToEmbeddedYAML(working, cm, key, rules)
+		if err != nil {
+			fmt.Printf("    Warning: Could not process embedded YAML in %s: %v\n", key, err)
+			continue
+		}
+		final := updatedValue
+		if !rulesModified {
+			final = working
+		}
+
+		if emit == "json" {
+			asJSON, err := embeddedYAMLToJSON(final)
+			if err != nil {
+				fmt.Printf("    Warning: Could not convert %s to JSON: %v\n", key, err)
+			} else {
+				final = asJSON
+			}
+		}
+
+		if final == original {
+			continue
+		}
+
+		modified = true
+		if dryRun {
+			basePath := fmt.Sprintf("configmaps[%d].data[%q]", cmIndex, key)
+			fileHunks, err := diffEmbeddedYAML(original, final, basePath)
+			if err != nil {
+				fmt.Printf("    Warning: Could not diff embedded YAML in %s: %v\n", key, err)
+				continue
+			}
+			hunks = append(hunks, fileHunks...)
+			continue
+		}
+
+		cm.Data[key] = final
+		if rulesModified {
+			fmt.Printf("    Modified embedded config in: %s (region: %s)\n", key, cm.Region)
+		} else if emit == "json" {
+			fmt.Printf("    Converted embedded config to JSON: %s (region: %s)\n", key, cm.Region)
+		}
+	}
+
+	return modified, hunks, nil
+}
+
+// applyRulesToEmbeddedYAML parses embeddedYAML, applies every rule whose
+// filter matches cm/filename, and re-marshals the result if anything
+// changed.
+func applyRulesToEmbeddedYAML(embeddedYAML string, cm *ConfigMap, filename string, rules []Rule) (string, bool, error) {
 	var data yaml.Node
 	if err := yaml.Unmarshal([]byte(embeddedYAML), &data); err != nil {
 		return embeddedYAML, false, fmt.Errorf("failed to parse embedded YAML: %w", err)
 	}
 
-	// Handle empty content
+	// Handle empty content: synthesize an empty mapping document so rules
+	// have somewhere to write.
 	if data.Kind == 0 {
-		// Empty document, create new structure
-		newYAML := "\nspring:\n  jackson:\n    default-property-inclusion: non_null\n"
-		return newYAML, true, nil
+		data = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
 	}
 
-	// Ensure we're working with a mapping
 	if data.Kind != yaml.DocumentNode || len(data.Content) == 0 {
 		return embeddedYAML, false, nil
 	}
@@ -167,81 +311,24 @@ func addSpringJacksonConfig(embeddedYAML string) (string, bool, error) {
 		return embeddedYAML, false, nil
 	}
 
-	// Look for existing spring key
-	springIndex := -1
-	for i := 0; i < len(root.Content); i += 2 {
-		if root.Content[i].Value == "spring" {
-			springIndex = i
-			break
+	modified := false
+	for _, rule := range rules {
+		if !ruleApplies(rule, cm, filename) {
+			continue
 		}
-	}
-
-	if springIndex >= 0 {
-		// spring key exists, add jackson under it
-		springValue := root.Content[springIndex+1]
-
-		if springValue.Kind != yaml.MappingNode {
-			// spring value is not a mapping, can't add jackson
-			return embeddedYAML, false, nil
+		changed, err := applyRule(root, rule)
+		if err != nil {
+			return embeddedYAML, false, fmt.Errorf("rule %q: %w", rule.Path, err)
 		}
-
-		// Check if jackson already exists under spring
-		for i := 0; i < len(springValue.Content); i += 2 {
-			if springValue.Content[i].Value == "jackson" {
-				// jackson already exists, check if default-property-inclusion exists
-				jacksonValue := springValue.Content[i+1]
-				if jacksonValue.Kind == yaml.MappingNode {
-					for j := 0; j < len(jacksonValue.Content); j += 2 {
-						if jacksonValue.Content[j].Value == "default-property-inclusion" {
-							// Already has the config
-							return embeddedYAML, false, nil
-						}
-					}
-					// Add default-property-inclusion to existing jackson
-					jacksonValue.Content = append(jacksonValue.Content,
-						&yaml.Node{Kind: yaml.ScalarNode, Value: "default-property-inclusion"},
-						&yaml.Node{Kind: yaml.ScalarNode, Value: "non_null"},
-					)
-				}
-				goto marshal
-			}
+		if changed {
+			modified = true
 		}
+	}
 
-		// jackson doesn't exist, add it to spring
-		jacksonNode := &yaml.Node{
-			Kind: yaml.MappingNode,
-			Content: []*yaml.Node{
-				{Kind: yaml.ScalarNode, Value: "default-property-inclusion"},
-				{Kind: yaml.ScalarNode, Value: "non_null"},
-			},
-		}
-		springValue.Content = append(springValue.Content,
-			&yaml.Node{Kind: yaml.ScalarNode, Value: "jackson"},
-			jacksonNode,
-		)
-	} else {
-		// spring key doesn't exist, add it
-		springNode := &yaml.Node{
-			Kind: yaml.MappingNode,
-			Content: []*yaml.Node{
-				{Kind: yaml.ScalarNode, Value: "jackson"},
-				{
-					Kind: yaml.MappingNode,
-					Content: []*yaml.Node{
-						{Kind: yaml.ScalarNode, Value: "default-property-inclusion"},
-						{Kind: yaml.ScalarNode, Value: "non_null"},
-					},
-				},
-			},
-		}
-		root.Content = append(root.Content,
-			&yaml.Node{Kind: yaml.ScalarNode, Value: "spring"},
-			springNode,
-		)
+	if !modified {
+		return embeddedYAML, false, nil
 	}
 
-marshal:
-	// Marshal back to YAML
 	var buf strings.Builder
 	encoder := yaml.NewEncoder(&buf)
 	encoder.SetIndent(2)
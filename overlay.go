@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// overlayDirFor returns the sibling `configmaps.d` directory for a given
+// `configmaps` directory, e.g. dev/configmaps -> dev/configmaps.d.
+func overlayDirFor(configmapsDir string) string {
+	return filepath.Join(filepath.Dir(configmapsDir), "configmaps.d")
+}
+
+// overlayFragments lists the YAML fragment files under overlayDir for a
+// given configmap name (overlayDir/<cmName>/*.yaml(.yml)). Returns nil,
+// nil if the fragment directory does not exist.
+func overlayFragments(overlayDir, cmName string) ([]string, error) {
+	fragDir := filepath.Join(overlayDir, cmName)
+	if _, err := os.Stat(fragDir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(fragDir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	ymlFiles, err := filepath.Glob(filepath.Join(fragDir, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+	return append(files, ymlFiles...), nil
+}
+
+// mergeEmbeddedYAML deep-merges overlayRaw into baseRaw: mappings merge
+// key-by-key, sequences concatenate when concatSequences is set
+// (otherwise the overlay sequence wins outright), and scalars from the
+// overlay win. A scalar/mapping/sequence type conflict between the two
+// sides is reported as an error naming the full YAML path. Reports
+// whether the result differs from baseRaw.
+func mergeEmbeddedYAML(baseRaw, overlayRaw string, concatSequences bool) (string, bool, error) {
+	baseRoot, err := parseMappingRoot(baseRaw)
+	if err != nil {
+		return baseRaw, false, fmt.Errorf("failed to parse base embedded YAML: %w", err)
+	}
+	overlayRoot, err := parseMappingRoot(overlayRaw)
+	if err != nil {
+		return baseRaw, false, fmt.Errorf("failed to parse overlay fragment: %w", err)
+	}
+	if overlayRoot == nil {
+		return baseRaw, false, nil
+	}
+
+	merged, changed, err := mergeOverlayNode(baseRoot, overlayRoot, "", concatSequences)
+	if err != nil {
+		return baseRaw, false, err
+	}
+	if !changed {
+		return baseRaw, false, nil
+	}
+
+	var buf strings.Builder
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(merged); err != nil {
+		return baseRaw, false, fmt.Errorf("failed to marshal merged YAML: %w", err)
+	}
+	encoder.Close()
+
+	result := buf.String()
+	if !strings.HasPrefix(result, "\n") {
+		result = "\n" + result
+	}
+	return result, true, nil
+}
+
+// mergeOverlayNode merges overlay into base (which may be nil, meaning
+// the base had no value at this path), returning the resulting node
+// and whether it differs from base. Re-merging an overlay that is
+// already fully present is a no-op (changed == false) in every case,
+// including concatenated sequences (detected via sequenceHasSuffix),
+// so repeated runs stay idempotent.
+func mergeOverlayNode(base, overlay *yaml.Node, path string, concatSequences bool) (*yaml.Node, bool, error) {
+	if base == nil {
+		return cloneNode(overlay), true, nil
+	}
+
+	if base.Kind != overlay.Kind {
+		return nil, false, fmt.Errorf("conflicting types at %q: base is %s, overlay is %s", path, nodeKindName(base.Kind), nodeKindName(overlay.Kind))
+	}
+
+	switch base.Kind {
+	case yaml.MappingNode:
+		changed := false
+		for i := 0; i < len(overlay.Content); i += 2 {
+			key := overlay.Content[i].Value
+			overlayValue := overlay.Content[i+1]
+
+			idx := findMappingKey(base, key)
+			if idx == -1 {
+				base.Content = append(base.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+					cloneNode(overlayValue),
+				)
+				changed = true
+				continue
+			}
+
+			merged, valueChanged, err := mergeOverlayNode(base.Content[idx+1], overlayValue, joinPath(path, key), concatSequences)
+			if err != nil {
+				return nil, false, err
+			}
+			if valueChanged {
+				base.Content[idx+1] = merged
+				changed = true
+			}
+		}
+		return base, changed, nil
+
+	case yaml.SequenceNode:
+		if !concatSequences {
+			if nodesEqual(base, overlay) {
+				return base, false, nil
+			}
+			return cloneNode(overlay), true, nil
+		}
+		if len(overlay.Content) == 0 || sequenceHasSuffix(base, overlay) {
+			return base, false, nil
+		}
+		for _, item := range overlay.Content {
+			base.Content = append(base.Content, cloneNode(item))
+		}
+		return base, true, nil
+
+	case yaml.ScalarNode:
+		if base.Tag != overlay.Tag {
+			return nil, false, fmt.Errorf("conflicting scalar types at %q: base is %s, overlay is %s", path, base.Tag, overlay.Tag)
+		}
+		if scalarsEqual(base, overlay) {
+			return base, false, nil
+		}
+		return cloneNode(overlay), true, nil
+
+	default:
+		return cloneNode(overlay), true, nil
+	}
+}
+
+// nodesEqual reports whether two nodes represent the same decoded
+// value, used to detect a no-op overlay replacement. Comparison is by
+// semantic value and type, not by marshaled text, so a plain-style
+// scalar (e.g. from a configmaps.d/ fragment) compares equal to the
+// same value re-parsed in quoted style (e.g. after a prior --emit=json
+// round trip) instead of spuriously registering as changed.
+func nodesEqual(a, b *yaml.Node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Kind != b.Kind {
+		return false
+	}
+
+	switch a.Kind {
+	case yaml.ScalarNode:
+		return scalarsEqual(a, b)
+
+	case yaml.SequenceNode:
+		if len(a.Content) != len(b.Content) {
+			return false
+		}
+		for i := range a.Content {
+			if !nodesEqual(a.Content[i], b.Content[i]) {
+				return false
+			}
+		}
+		return true
+
+	case yaml.MappingNode:
+		if len(a.Content) != len(b.Content) {
+			return false
+		}
+		for i := 0; i < len(a.Content); i += 2 {
+			key := a.Content[i].Value
+			idx := findMappingKey(b, key)
+			if idx == -1 || !nodesEqual(a.Content[i+1], b.Content[idx+1]) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return a.Value == b.Value
+	}
+}
+
+// scalarsEqual compares two scalar nodes by decoded value and type
+// (via scalarToJSON) rather than their literal text, so quote style
+// doesn't affect the comparison.
+func scalarsEqual(a, b *yaml.Node) bool {
+	aVal, aErr := scalarToJSON(a)
+	bVal, bErr := scalarToJSON(b)
+	if aErr != nil || bErr != nil {
+		return a.Value == b.Value
+	}
+	return aVal == bVal
+}
+
+// sequenceHasSuffix reports whether base's trailing elements already
+// equal overlay's elements in order, which means a prior run already
+// concatenated this overlay in and doing so again would just be
+// growing the sequence with a duplicate copy.
+func sequenceHasSuffix(base, overlay *yaml.Node) bool {
+	if len(overlay.Content) > len(base.Content) {
+		return false
+	}
+	offset := len(base.Content) - len(overlay.Content)
+	for i, item := range overlay.Content {
+		if !nodesEqual(base.Content[offset+i], item) {
+			return false
+		}
+	}
+	return true
+}
+
+func nodeKindName(kind yaml.Kind) string {
+	switch kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "sequence"
+	case yaml.ScalarNode:
+		return "scalar"
+	case yaml.AliasNode:
+		return "alias"
+	case yaml.DocumentNode:
+		return "document"
+	default:
+		return "unknown"
+	}
+}
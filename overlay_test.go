@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestMergeEmbeddedYAMLConcatIsIdempotent(t *testing.T) {
+	base := "list:\n  - a\n  - b\n"
+	overlay := "list:\n  - c\n  - d\n"
+
+	merged, changed, err := mergeEmbeddedYAML(base, overlay, true)
+	if err != nil {
+		t.Fatalf("mergeEmbeddedYAML: %v", err)
+	}
+	if !changed {
+		t.Fatal("expected the first merge to report a change")
+	}
+
+	again, changed, err := mergeEmbeddedYAML(merged, overlay, true)
+	if err != nil {
+		t.Fatalf("mergeEmbeddedYAML (second run): %v", err)
+	}
+	if changed {
+		t.Fatalf("expected re-merging an already-applied overlay to be a no-op, got new raw:\n%s", again)
+	}
+	if again != merged {
+		t.Fatalf("expected second run to leave the document unchanged:\ngot:  %q\nwant: %q", again, merged)
+	}
+}
+
+func TestMergeEmbeddedYAMLConcatIsIdempotentAcrossJSONRoundTrip(t *testing.T) {
+	base := "list:\n  - a\n  - b\n"
+	overlay := "list:\n  - c\n  - d\n"
+
+	merged, _, err := mergeEmbeddedYAML(base, overlay, true)
+	if err != nil {
+		t.Fatalf("mergeEmbeddedYAML: %v", err)
+	}
+
+	// Simulate a prior --emit=json run: every scalar in the document
+	// comes back as a double-quoted string once re-parsed as YAML.
+	asJSON, err := embeddedYAMLToJSON(merged)
+	if err != nil {
+		t.Fatalf("embeddedYAMLToJSON: %v", err)
+	}
+
+	again, changed, err := mergeEmbeddedYAML(asJSON, overlay, true)
+	if err != nil {
+		t.Fatalf("mergeEmbeddedYAML (post-JSON run): %v", err)
+	}
+	if changed {
+		t.Fatalf("expected re-merging the overlay after a JSON round trip to be a no-op, got:\n%s", again)
+	}
+}
+
+func TestMergeEmbeddedYAMLReplaceSequenceNoOpWhenAlreadyApplied(t *testing.T) {
+	base := "list:\n  - c\n  - d\n"
+	overlay := "list:\n  - c\n  - d\n"
+
+	_, changed, err := mergeEmbeddedYAML(base, overlay, false)
+	if err != nil {
+		t.Fatalf("mergeEmbeddedYAML: %v", err)
+	}
+	if changed {
+		t.Fatal("expected replace-mode merge to be a no-op when the overlay already matches the base")
+	}
+}
+
+func TestMergeEmbeddedYAMLScalarConflictIsReported(t *testing.T) {
+	base := "x: hello\n"
+	overlay := "x:\n  y: 1\n"
+
+	if _, _, err := mergeEmbeddedYAML(base, overlay, true); err == nil {
+		t.Fatal("expected a type-conflict error merging a scalar base with a mapping overlay")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// refResolver resolves $ref pointers found inside embedded YAML
+// documents, either against the same document (#/a/b) or a sibling
+// file on disk (shared.yaml#/a/b). It caches parsed external documents
+// and accumulates a run-wide summary of what was resolved or skipped.
+type refResolver struct {
+	externalCache map[string]*yaml.Node
+	resolved      []string
+	skipped       []string
+}
+
+func newRefResolver() *refResolver {
+	return &refResolver{externalCache: make(map[string]*yaml.Node)}
+}
+
+// resolveTree walks root (the parsed root of an embedded YAML document
+// identified by selfID, e.g. "dev/configmaps/foo.yaml:mycm[application.yaml]")
+// inlining every $ref it finds. It reports whether anything changed.
+func (r *refResolver) resolveTree(root *yaml.Node, selfID, baseDir string) (bool, error) {
+	stack := make(map[string]bool)
+	return r.walk(root, selfID, root, baseDir, stack)
+}
+
+// walk recurses through node looking for mapping nodes whose sole key
+// is $ref, inlining the resolved subtree in place.
+func (r *refResolver) walk(node *yaml.Node, selfID string, selfRoot *yaml.Node, baseDir string, stack map[string]bool) (bool, error) {
+	if node == nil {
+		return false, nil
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) == 2 && node.Content[0].Value == "$ref" {
+			refStr := node.Content[1].Value
+			resolved, err := r.resolve(refStr, selfID, selfRoot, baseDir, stack)
+			if err != nil {
+				return false, err
+			}
+			if resolved == nil {
+				r.skipped = append(r.skipped, fmt.Sprintf("%s: $ref %q (target not found)", selfID, refStr))
+				return false, nil
+			}
+			*node = *resolved
+			r.resolved = append(r.resolved, fmt.Sprintf("%s: $ref %q", selfID, refStr))
+			return true, nil
+		}
+
+		changed := false
+		for i := 1; i < len(node.Content); i += 2 {
+			childChanged, err := r.walk(node.Content[i], selfID, selfRoot, baseDir, stack)
+			if err != nil {
+				return false, err
+			}
+			changed = changed || childChanged
+		}
+		return changed, nil
+
+	case yaml.SequenceNode:
+		changed := false
+		for _, item := range node.Content {
+			childChanged, err := r.walk(item, selfID, selfRoot, baseDir, stack)
+			if err != nil {
+				return false, err
+			}
+			changed = changed || childChanged
+		}
+		return changed, nil
+
+	default:
+		return false, nil
+	}
+}
+
+// resolve dereferences a single $ref value, fully resolving any refs
+// nested within its target before returning it.
+func (r *refResolver) resolve(refStr, selfID string, selfRoot *yaml.Node, baseDir string, stack map[string]bool) (*yaml.Node, error) {
+	file, pointer := parseRef(refStr)
+
+	targetID := selfID
+	targetRoot := selfRoot
+	targetBaseDir := baseDir
+	if file != "" {
+		absPath := filepath.Join(baseDir, file)
+		targetID = absPath
+		targetBaseDir = filepath.Dir(absPath)
+
+		root, err := r.loadExternal(absPath)
+		if err != nil {
+			return nil, nil
+		}
+		targetRoot = root
+	}
+
+	stackKey := targetID + "#" + pointer
+	if stack[stackKey] {
+		return nil, fmt.Errorf("cycle detected resolving $ref %q (already resolving %s)", refStr, stackKey)
+	}
+
+	target, err := resolvePointer(targetRoot, pointer)
+	if err != nil || target == nil {
+		return nil, nil
+	}
+
+	stack[stackKey] = true
+	resolved := cloneNode(target)
+	if _, err := r.walk(resolved, targetID, targetRoot, targetBaseDir, stack); err != nil {
+		delete(stack, stackKey)
+		return nil, err
+	}
+	delete(stack, stackKey)
+
+	return resolved, nil
+}
+
+// loadExternal parses and caches the YAML document at absPath.
+func (r *refResolver) loadExternal(absPath string) (*yaml.Node, error) {
+	if root, ok := r.externalCache[absPath]; ok {
+		return root, nil
+	}
+
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("%s: empty document", absPath)
+	}
+
+	root := doc.Content[0]
+	r.externalCache[absPath] = root
+	return root, nil
+}
+
+// resolveEmbeddedRefs parses raw embedded YAML, resolves every $ref it
+// contains via resolver (scoped to filePath/cm/key for diagnostics and
+// cycle detection), and re-marshals the result if anything changed.
+func resolveEmbeddedRefs(raw, filePath string, cm *ConfigMap, key string, resolver *refResolver) (string, bool, error) {
+	root, err := parseMappingRoot(raw)
+	if err != nil {
+		return raw, false, fmt.Errorf("failed to parse embedded YAML: %w", err)
+	}
+	if root == nil {
+		return raw, false, nil
+	}
+
+	selfID := fmt.Sprintf("%s:%s[%s]", filePath, cm.Name, key)
+	changed, err := resolver.resolveTree(root, selfID, filepath.Dir(filePath))
+	if err != nil {
+		return raw, false, err
+	}
+	if !changed {
+		return raw, false, nil
+	}
+
+	var buf strings.Builder
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return raw, false, fmt.Errorf("failed to marshal dereferenced YAML: %w", err)
+	}
+	encoder.Close()
+
+	result := buf.String()
+	if !strings.HasPrefix(result, "\n") {
+		result = "\n" + result
+	}
+	return result, true, nil
+}
+
+// printSummary reports every $ref resolved during the run and any that
+// were skipped because their target could not be found.
+func (r *refResolver) printSummary() {
+	if len(r.resolved) == 0 && len(r.skipped) == 0 {
+		return
+	}
+
+	fmt.Println("\n$ref resolution summary:")
+	for _, entry := range r.resolved {
+		fmt.Printf("  resolved: %s\n", entry)
+	}
+	for _, entry := range r.skipped {
+		fmt.Printf("  skipped:  %s\n", entry)
+	}
+}
+
+// parseRef splits a $ref value into an optional external file and a
+// JSON-Pointer fragment, e.g. "shared.yaml#/spring" -> ("shared.yaml",
+// "/spring"), or "#/components/x" -> ("", "/components/x").
+func parseRef(ref string) (file, pointer string) {
+	if strings.HasPrefix(ref, "#") {
+		return "", strings.TrimPrefix(ref, "#")
+	}
+	parts := strings.SplitN(ref, "#", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+// resolvePointer walks a JSON Pointer (RFC 6901) against a yaml.Node
+// tree. It returns nil, nil if the pointer does not resolve to
+// anything, rather than an error, so callers can report a "skipped" ref.
+func resolvePointer(root *yaml.Node, pointer string) (*yaml.Node, error) {
+	pointer = strings.TrimPrefix(pointer, "/")
+	if pointer == "" {
+		return root, nil
+	}
+
+	node := root
+	for _, raw := range strings.Split(pointer, "/") {
+		segment := strings.ReplaceAll(strings.ReplaceAll(raw, "~1", "/"), "~0", "~")
+
+		switch node.Kind {
+		case yaml.MappingNode:
+			idx := findMappingKey(node, segment)
+			if idx == -1 {
+				return nil, nil
+			}
+			node = node.Content[idx+1]
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node.Content) {
+				return nil, nil
+			}
+			node = node.Content[i]
+		default:
+			return nil, nil
+		}
+	}
+
+	return node, nil
+}
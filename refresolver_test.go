@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveEmbeddedRefsSameDocument(t *testing.T) {
+	raw := "db:\n  $ref: \"#/shared/db\"\nshared:\n  db:\n    url: jdbc:postgresql://host/db\n"
+
+	resolver := newRefResolver()
+	cm := &ConfigMap{Name: "mycm"}
+	result, changed, err := resolveEmbeddedRefs(raw, "dev/configmaps/app.yaml", cm, "application.yaml", resolver)
+	if err != nil {
+		t.Fatalf("resolveEmbeddedRefs: %v", err)
+	}
+	if !changed {
+		t.Fatal("expected resolving a same-document $ref to report a change")
+	}
+
+	root, err := parseMappingRoot(result)
+	if err != nil {
+		t.Fatalf("parseMappingRoot(%q): %v", result, err)
+	}
+	idx := findMappingKey(root, "db")
+	db := root.Content[idx+1]
+	idx = findMappingKey(db, "url")
+	if got := db.Content[idx+1].Value; got != "jdbc:postgresql://host/db" {
+		t.Fatalf("got db.url=%q, want resolved URL", got)
+	}
+}
+
+func TestResolveEmbeddedRefsExternalFile(t *testing.T) {
+	dir := t.TempDir()
+	sharedPath := filepath.Join(dir, "shared.yaml")
+	if err := os.WriteFile(sharedPath, []byte("db:\n  url: jdbc:postgresql://shared-host/db\n"), 0644); err != nil {
+		t.Fatalf("write shared.yaml: %v", err)
+	}
+
+	raw := "db:\n  $ref: \"shared.yaml#/db\"\n"
+	resolver := newRefResolver()
+	cm := &ConfigMap{Name: "mycm"}
+	result, changed, err := resolveEmbeddedRefs(raw, filepath.Join(dir, "app.yaml"), cm, "application.yaml", resolver)
+	if err != nil {
+		t.Fatalf("resolveEmbeddedRefs: %v", err)
+	}
+	if !changed {
+		t.Fatal("expected resolving an external $ref to report a change")
+	}
+
+	root, err := parseMappingRoot(result)
+	if err != nil {
+		t.Fatalf("parseMappingRoot(%q): %v", result, err)
+	}
+	idx := findMappingKey(root, "db")
+	db := root.Content[idx+1]
+	idx = findMappingKey(db, "url")
+	if got := db.Content[idx+1].Value; got != "jdbc:postgresql://shared-host/db" {
+		t.Fatalf("got db.url=%q, want resolved URL", got)
+	}
+	if len(resolver.resolved) != 1 {
+		t.Fatalf("expected exactly one resolved $ref recorded, got %v", resolver.resolved)
+	}
+}
+
+func TestResolveEmbeddedRefsDetectsSameDocumentCycle(t *testing.T) {
+	raw := "a:\n  $ref: \"#/b\"\nb:\n  $ref: \"#/a\"\n"
+
+	resolver := newRefResolver()
+	cm := &ConfigMap{Name: "mycm"}
+	if _, _, err := resolveEmbeddedRefs(raw, "dev/configmaps/app.yaml", cm, "application.yaml", resolver); err == nil {
+		t.Fatal("expected a cycle error resolving mutually-referencing $refs")
+	}
+}
+
+func TestResolveEmbeddedRefsDetectsExternalCycle(t *testing.T) {
+	dir := t.TempDir()
+	aPath := filepath.Join(dir, "a.yaml")
+	bPath := filepath.Join(dir, "b.yaml")
+	if err := os.WriteFile(aPath, []byte("x:\n  $ref: \"b.yaml#/y\"\n"), 0644); err != nil {
+		t.Fatalf("write a.yaml: %v", err)
+	}
+	if err := os.WriteFile(bPath, []byte("y:\n  $ref: \"a.yaml#/x\"\n"), 0644); err != nil {
+		t.Fatalf("write b.yaml: %v", err)
+	}
+
+	raw := "root:\n  $ref: \"a.yaml#/x\"\n"
+	resolver := newRefResolver()
+	cm := &ConfigMap{Name: "mycm"}
+	if _, _, err := resolveEmbeddedRefs(raw, filepath.Join(dir, "app.yaml"), cm, "application.yaml", resolver); err == nil {
+		t.Fatal("expected a cycle error resolving refs across two external files that point back at each other")
+	}
+}
+
+func TestResolveEmbeddedRefsMissingTargetIsSkippedNotFatal(t *testing.T) {
+	raw := "db:\n  $ref: \"#/does/not/exist\"\n"
+
+	resolver := newRefResolver()
+	cm := &ConfigMap{Name: "mycm"}
+	_, changed, err := resolveEmbeddedRefs(raw, "dev/configmaps/app.yaml", cm, "application.yaml", resolver)
+	if err != nil {
+		t.Fatalf("resolveEmbeddedRefs: %v", err)
+	}
+	if changed {
+		t.Fatal("expected an unresolved $ref to be left in place, not reported as a change")
+	}
+	if len(resolver.skipped) != 1 {
+		t.Fatalf("expected exactly one skipped $ref recorded, got %v", resolver.skipped)
+	}
+}
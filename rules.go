@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MergePolicy controls how a Rule's value is combined with whatever is
+// already present at its target path.
+type MergePolicy string
+
+const (
+	// MergeSkipIfPresent leaves the target untouched if the path already
+	// exists. This is the historical behavior of addSpringJacksonConfig.
+	MergeSkipIfPresent MergePolicy = "skip-if-present"
+	// MergeOverwrite replaces the target value outright.
+	MergeOverwrite MergePolicy = "overwrite"
+	// MergeMap deep-merges a mapping value into the existing mapping,
+	// with the rule's keys winning on conflicting scalars.
+	MergeMap MergePolicy = "merge-map"
+)
+
+// RuleFilter restricts a Rule to the configmaps/files it applies to.
+// Empty fields are treated as wildcards.
+type RuleFilter struct {
+	Region    string `yaml:"region,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+	Filename  string `yaml:"filename,omitempty"` // glob matched against filepath.Base
+}
+
+// Rule describes a single injection: set Path to Value under the given
+// MergePolicy, optionally scoped by Filter.
+type Rule struct {
+	Path   string      `yaml:"path"`
+	Value  yaml.Node   `yaml:"value"`
+	Merge  MergePolicy `yaml:"merge"`
+	Filter RuleFilter  `yaml:"filter,omitempty"`
+}
+
+// RuleSet is the top-level shape of a --rules YAML file.
+type RuleSet struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// defaultRules reproduces the tool's original, hard-coded behavior so
+// that running without --rules is unchanged.
+func defaultRules() []Rule {
+	return []Rule{
+		{
+			Path: "spring.jackson.default-property-inclusion",
+			Value: yaml.Node{
+				Kind:  yaml.ScalarNode,
+				Value: "non_null",
+			},
+			Merge: MergeSkipIfPresent,
+		},
+	}
+}
+
+// loadRuleSet reads and parses a rules file as produced by --rules.
+func loadRuleSet(path string) ([]Rule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	var set RuleSet
+	if err := yaml.Unmarshal(content, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+
+	for i, rule := range set.Rules {
+		if rule.Path == "" {
+			return nil, fmt.Errorf("rule %d: path is required", i)
+		}
+		if rule.Merge == "" {
+			set.Rules[i].Merge = MergeSkipIfPresent
+		}
+	}
+
+	return set.Rules, nil
+}
+
+// ruleApplies reports whether rule's filter matches the given configmap
+// and embedded filename (the data key, e.g. "application.yaml").
+func ruleApplies(rule Rule, cm *ConfigMap, filename string) bool {
+	if rule.Filter.Region != "" && rule.Filter.Region != cm.Region {
+		return false
+	}
+	if rule.Filter.Namespace != "" && rule.Filter.Namespace != cm.Namespace {
+		return false
+	}
+	if rule.Filter.Filename != "" {
+		matched, err := filepath.Match(rule.Filter.Filename, filename)
+		if err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// applyRule resolves rule.Path against root (a yaml.Node MappingNode),
+// auto-creating intermediate mappings as needed, and applies rule.Value
+// under rule.Merge. It reports whether the tree was modified.
+func applyRule(root *yaml.Node, rule Rule) (bool, error) {
+	segments := strings.Split(rule.Path, ".")
+	node := root
+
+	for i, segment := range segments {
+		if node.Kind != yaml.MappingNode {
+			return false, fmt.Errorf("path %q: %q is not a mapping", rule.Path, strings.Join(segments[:i], "."))
+		}
+
+		last := i == len(segments)-1
+		idx := findMappingKey(node, segment)
+
+		if idx == -1 {
+			var child *yaml.Node
+			if last {
+				child = cloneNode(&rule.Value)
+			} else {
+				child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			}
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: segment},
+				child,
+			)
+			if last {
+				return true, nil
+			}
+			node = child
+			continue
+		}
+
+		existing := node.Content[idx+1]
+		if !last {
+			node = existing
+			continue
+		}
+
+		switch rule.Merge {
+		case MergeSkipIfPresent, "":
+			return false, nil
+		case MergeOverwrite:
+			*existing = *cloneNode(&rule.Value)
+			return true, nil
+		case MergeMap:
+			return mergeMappingNode(existing, &rule.Value), nil
+		default:
+			return false, fmt.Errorf("unknown merge policy %q", rule.Merge)
+		}
+	}
+
+	return false, nil
+}
+
+// findMappingKey returns the index of key's name node within a
+// MappingNode's Content pairs, or -1 if absent.
+func findMappingKey(mapping *yaml.Node, key string) int {
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeMappingNode deep-merges src into dst in place. Keys present only
+// in src are appended (preserving dst's existing key order), mapping
+// values recurse, and scalars from src win. Reports whether dst changed.
+func mergeMappingNode(dst, src *yaml.Node) bool {
+	if src.Kind != yaml.MappingNode || dst.Kind != yaml.MappingNode {
+		if dst.Value == src.Value && dst.Kind == src.Kind {
+			return false
+		}
+		*dst = *cloneNode(src)
+		return true
+	}
+
+	changed := false
+	for i := 0; i < len(src.Content); i += 2 {
+		key := src.Content[i].Value
+		srcValue := src.Content[i+1]
+
+		idx := findMappingKey(dst, key)
+		if idx == -1 {
+			dst.Content = append(dst.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+				cloneNode(srcValue),
+			)
+			changed = true
+			continue
+		}
+
+		dstValue := dst.Content[idx+1]
+		if dstValue.Kind == yaml.MappingNode && srcValue.Kind == yaml.MappingNode {
+			if mergeMappingNode(dstValue, srcValue) {
+				changed = true
+			}
+			continue
+		}
+		if dstValue.Value != srcValue.Value || dstValue.Kind != srcValue.Kind {
+			*dstValue = *cloneNode(srcValue)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// cloneNode deep-copies a yaml.Node via marshal/unmarshal round-trip,
+// since yaml.Node holds pointers that must not be shared across call
+// sites (e.g. the same rule applied to many files).
+func cloneNode(n *yaml.Node) *yaml.Node {
+	out, err := yaml.Marshal(n)
+	if err != nil {
+		// n was already successfully parsed once; this should not happen.
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: n.Value, Tag: n.Tag}
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(out, &doc); err != nil {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: n.Value, Tag: n.Tag}
+	}
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		return doc.Content[0]
+	}
+	return &doc
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func mustParseMapping(t *testing.T, raw string) *yaml.Node {
+	t.Helper()
+	root, err := parseMappingRoot(raw)
+	if err != nil {
+		t.Fatalf("parseMappingRoot(%q): %v", raw, err)
+	}
+	if root == nil {
+		root = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	return root
+}
+
+func scalarRule(path, value string, merge MergePolicy) Rule {
+	return Rule{
+		Path:  path,
+		Value: yaml.Node{Kind: yaml.ScalarNode, Value: value},
+		Merge: merge,
+	}
+}
+
+func TestApplyRuleCreatesIntermediatePath(t *testing.T) {
+	root := mustParseMapping(t, "foo: bar\n")
+
+	changed, err := applyRule(root, scalarRule("spring.jackson.default-property-inclusion", "non_null", MergeSkipIfPresent))
+	if err != nil {
+		t.Fatalf("applyRule: %v", err)
+	}
+	if !changed {
+		t.Fatal("expected applyRule to report a change when creating a new path")
+	}
+
+	out, err := yaml.Marshal(root)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := "foo: bar\nspring:\n    jackson:\n        default-property-inclusion: non_null\n"
+	if string(out) != want {
+		t.Fatalf("unexpected tree after applyRule:\ngot:  %q\nwant: %q", string(out), want)
+	}
+}
+
+func TestApplyRuleSkipIfPresentLeavesExistingValue(t *testing.T) {
+	root := mustParseMapping(t, "spring:\n  jackson:\n    default-property-inclusion: always\n")
+
+	changed, err := applyRule(root, scalarRule("spring.jackson.default-property-inclusion", "non_null", MergeSkipIfPresent))
+	if err != nil {
+		t.Fatalf("applyRule: %v", err)
+	}
+	if changed {
+		t.Fatal("expected skip-if-present to leave an existing value untouched")
+	}
+
+	idx := findMappingKey(root, "spring")
+	jackson := root.Content[idx+1]
+	idx = findMappingKey(jackson, "jackson")
+	inclusion := jackson.Content[idx+1]
+	idx = findMappingKey(inclusion, "default-property-inclusion")
+	if got := inclusion.Content[idx+1].Value; got != "always" {
+		t.Fatalf("expected existing value %q to survive, got %q", "always", got)
+	}
+}
+
+func TestApplyRuleOverwriteReplacesExistingValue(t *testing.T) {
+	root := mustParseMapping(t, "level: debug\n")
+
+	changed, err := applyRule(root, scalarRule("level", "warn", MergeOverwrite))
+	if err != nil {
+		t.Fatalf("applyRule: %v", err)
+	}
+	if !changed {
+		t.Fatal("expected overwrite to report a change")
+	}
+
+	idx := findMappingKey(root, "level")
+	if got := root.Content[idx+1].Value; got != "warn" {
+		t.Fatalf("got level=%q, want %q", got, "warn")
+	}
+
+	// Re-applying the same overwrite rule still reports changed, since
+	// overwrite does not compare against the existing value.
+	changed, err = applyRule(root, scalarRule("level", "warn", MergeOverwrite))
+	if err != nil {
+		t.Fatalf("applyRule (second): %v", err)
+	}
+	if !changed {
+		t.Fatal("expected overwrite to always report changed, even re-applying the same value")
+	}
+}
+
+func TestApplyRuleMergeMapDeepMergesKeys(t *testing.T) {
+	root := mustParseMapping(t, "management:\n  endpoints:\n    web:\n      exposure:\n        include: health\n")
+
+	ruleValue := mustParseMapping(t, "endpoints:\n  web:\n    exposure:\n      exclude: env\n")
+	rule := Rule{
+		Path:  "management",
+		Value: *ruleValue,
+		Merge: MergeMap,
+	}
+
+	changed, err := applyRule(root, rule)
+	if err != nil {
+		t.Fatalf("applyRule: %v", err)
+	}
+	if !changed {
+		t.Fatal("expected merge-map to report a change when adding a new key")
+	}
+
+	out, err := yaml.Marshal(root)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := "management:\n    endpoints:\n        web:\n            exposure:\n                include: health\n                exclude: env\n"
+	if string(out) != want {
+		t.Fatalf("unexpected tree after merge-map:\ngot:  %q\nwant: %q", string(out), want)
+	}
+
+	// Re-applying the identical rule is a no-op: every key/value already
+	// present, so nothing should be reported as changed.
+	changed, err = applyRule(root, rule)
+	if err != nil {
+		t.Fatalf("applyRule (second): %v", err)
+	}
+	if changed {
+		t.Fatal("expected re-applying an already-merged merge-map rule to be a no-op")
+	}
+}